@@ -3,22 +3,35 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"io/ioutil"
 	"math"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"golang.org/x/sync/singleflight"
 )
 
 // Get the file and line number for logging clarity
@@ -68,6 +81,265 @@ const (
 	TRANSFORM_TRUNCATE_FRAC_SECS    = iota
 )
 
+// Define which aggregate field becomes the primary "Value" field when a query is binned
+// client-side (see buildAggregateFrame). This maps onto the aggregationOptions list in QueryEditor.tsx.
+const (
+	AGGREGATION_MEAN   = iota
+	AGGREGATION_MIN    = iota
+	AGGREGATION_MAX    = iota
+	AGGREGATION_COUNT  = iota
+	AGGREGATION_STDDEV = iota
+	AGGREGATION_FIRST  = iota
+	AGGREGATION_LAST   = iota
+)
+
+// EPICS_SEVERITY_INVALID is the archiver's alarm severity code for a sample that should not be
+// trusted numerically (disconnected channel, calc error, etc). Aggregates exclude these.
+const EPICS_SEVERITY_INVALID = 3
+
+// Sentinel errors so callers (and downstream transformation nodes) can use errors.Is to
+// distinguish "PV doesn't exist" from "archiver is down" instead of matching on frame emptiness.
+var (
+	ErrPVNotFound          = errors.New("pv not found")
+	ErrArchiverUnavailable = errors.New("archiver unavailable")
+	ErrBadTimeRange        = errors.New("bad time range")
+)
+
+// classifyArchiverError turns a failed request or a 4xx/5xx archiver response into one of the
+// sentinel errors above, wrapped with enough detail for the log but still errors.Is-matchable. It
+// returns nil for any response with a status below 400.
+func classifyArchiverError(res *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrArchiverUnavailable, err.Error())
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrPVNotFound, res.Status)
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrBadTimeRange, res.Status)
+	default:
+		if res.StatusCode >= 400 {
+			return fmt.Errorf("%w: archiver returned %s", ErrArchiverUnavailable, res.Status)
+		}
+		return nil
+	}
+}
+
+// toUint8Slice narrows archiver severity/status codes (small positive ints) down to the uint8
+// fields Grafana frames carry them as.
+func toUint8Slice(in []int64) []uint8 {
+	out := make([]uint8, len(in))
+	for i, v := range in {
+		out[i] = uint8(v)
+	}
+	return out
+}
+
+// epicsSeverityFieldConfig maps the EPICS alarm severity scale (NO_ALARM/MINOR/MAJOR/INVALID)
+// onto Grafana color thresholds so a panel can color-code samples by alarm state out of the box.
+func epicsSeverityFieldConfig() *data.FieldConfig {
+	return &data.FieldConfig{
+		Thresholds: &data.ThresholdsConfig{
+			Mode: data.ThresholdsModeAbsolute,
+			Steps: []data.Threshold{
+				{Value: data.ConfFloat64(math.NaN()), Color: "green", State: "NO_ALARM"},
+				{Value: data.ConfFloat64(1), Color: "yellow", State: "MINOR"},
+				{Value: data.ConfFloat64(2), Color: "red", State: "MAJOR"},
+				{Value: data.ConfFloat64(EPICS_SEVERITY_INVALID), Color: "purple", State: "INVALID"},
+			},
+		},
+	}
+}
+
+// convertUnit applies one of the UNIT_CONVERT_* conversions to a single raw archiver value.
+// Both the historical query path and the live stream path share this so a streamed PV always
+// matches the units a panel would have gotten from a regular query.
+func convertUnit(val float64, kind int) (float64, error) {
+	switch kind {
+
+	case UNIT_CONVERT_NONE:
+		// No conversion, just return it straight over
+		return val, nil
+
+	case UNIT_CONVERT_DEG_TO_RAD:
+		// RAD = DEG * π/180  (1° = 0.01745rad)
+		return val * (math.Pi / 180), nil
+
+	case UNIT_CONVERT_RAD_TO_DEG:
+		// DEG = RAD * 180/π  (1rad = 57.296°)
+		return val * (180 / math.Pi), nil
+
+	case UNIT_CONVERT_RAD_TO_ARCSEC:
+		// ARCSEC = RAD * (3600 * 180)/π  (1rad = 206264.806")
+		return val * (3600 * 180 / math.Pi), nil
+
+	case UNIT_CONVERT_K_TO_C:
+		// °C = K + 273.15
+		return val + 273.15, nil
+
+	case UNIT_CONVERT_C_TO_K:
+		// K = °C − 273.15
+		return val - 273.15, nil
+
+	case UNIT_CONVERT_F_TO_C:
+		// °C = (°F − 32) × 5⁄9
+		return (val - 32) * 5 / 9, nil
+
+	case UNIT_CONVERT_C_TO_F:
+		// °F = (°C * 9/5) + 32
+		return (val * 9 / 5) + 32, nil
+
+	default:
+		return 0, fmt.Errorf("Unknown unit conversion: %d", kind)
+	}
+}
+
+// aggregateBin accumulates the samples that fall into a single client-side bin.
+type aggregateBin struct {
+	sum, sumSq, min, max, first, last float64
+	count                             int64
+	hasValid                          bool
+}
+
+// buildAggregateFrame groups (times, values) into bucketMs-wide buckets starting at from, and
+// emits a frame with one row per bucket and a parallel field per aggregate (mean/min/max/count/
+// stddev/first/last), plus a "valid" field and a "Value" field mirroring whichever aggregate
+// qm.Aggregation selects. Samples with EPICS_SEVERITY_INVALID or NaN are left out of the
+// aggregates, but still count towards marking their bucket as seen.
+func buildAggregateFrame(qm queryModel, from time.Time, bucketMs int64, times []time.Time, values []float64, severities []int64) (*data.Frame, error) {
+	if bucketMs < 1 {
+		bucketMs = 1
+	}
+
+	bins := map[int64]*aggregateBin{}
+	var maxIdx int64
+	var sawAny bool
+
+	for i, t := range times {
+		idx := t.Sub(from).Milliseconds() / bucketMs
+		if idx < 0 {
+			// The archiver commonly returns one boundary sample timestamped at or slightly
+			// before "from" so a chart has a value to start from; fold it into the first bucket
+			// instead of dropping it on the floor.
+			idx = 0
+		}
+		if !sawAny || idx > maxIdx {
+			maxIdx = idx
+		}
+		sawAny = true
+
+		b, ok := bins[idx]
+		if !ok {
+			b = &aggregateBin{min: math.Inf(1), max: math.Inf(-1)}
+			bins[idx] = b
+		}
+
+		v := values[i]
+		if severities[i] == EPICS_SEVERITY_INVALID || math.IsNaN(v) {
+			continue
+		}
+
+		if !b.hasValid {
+			b.first = v
+		}
+		b.last = v
+		b.sum += v
+		b.sumSq += v * v
+		if v < b.min {
+			b.min = v
+		}
+		if v > b.max {
+			b.max = v
+		}
+		b.count++
+		b.hasValid = true
+	}
+
+	var numBuckets int64
+	if sawAny {
+		numBuckets = maxIdx + 1
+	}
+
+	bucketTimes := make([]time.Time, numBuckets)
+	means := make([]float64, numBuckets)
+	mins := make([]float64, numBuckets)
+	maxs := make([]float64, numBuckets)
+	counts := make([]int64, numBuckets)
+	stddevs := make([]float64, numBuckets)
+	firsts := make([]float64, numBuckets)
+	lasts := make([]float64, numBuckets)
+	valids := make([]bool, numBuckets)
+
+	for idx := int64(0); idx < numBuckets; idx++ {
+		bucketTimes[idx] = from.Add(time.Duration(idx*bucketMs) * time.Millisecond)
+
+		b, ok := bins[idx]
+		if !ok || !b.hasValid {
+			continue
+		}
+
+		mean := b.sum / float64(b.count)
+		variance := b.sumSq/float64(b.count) - mean*mean
+		if variance < 0 {
+			// Guard against floating point noise producing a tiny negative variance.
+			variance = 0
+		}
+
+		means[idx] = mean
+		mins[idx] = b.min
+		maxs[idx] = b.max
+		counts[idx] = b.count
+		stddevs[idx] = math.Sqrt(variance)
+		firsts[idx] = b.first
+		lasts[idx] = b.last
+		valids[idx] = true
+	}
+
+	var primary []float64
+	switch qm.Aggregation {
+	case AGGREGATION_MEAN:
+		primary = means
+	case AGGREGATION_MIN:
+		primary = mins
+	case AGGREGATION_MAX:
+		primary = maxs
+	case AGGREGATION_COUNT:
+		primary = make([]float64, numBuckets)
+		for idx, c := range counts {
+			primary[idx] = float64(c)
+		}
+	case AGGREGATION_STDDEV:
+		primary = stddevs
+	case AGGREGATION_FIRST:
+		primary = firsts
+	case AGGREGATION_LAST:
+		primary = lasts
+	default:
+		return nil, fmt.Errorf("Unknown aggregation: %d", qm.Aggregation)
+	}
+
+	frame := data.NewFrame("response")
+	frame.RefID = qm.RefId
+	frame.Name = qm.QueryText
+	frame.Fields = append(frame.Fields,
+		data.NewField("Time", nil, bucketTimes),
+		data.NewField("Value", nil, primary),
+		data.NewField("mean", nil, means),
+		data.NewField("min", nil, mins),
+		data.NewField("max", nil, maxs),
+		data.NewField("count", nil, counts),
+		data.NewField("stddev", nil, stddevs),
+		data.NewField("first", nil, firsts),
+		data.NewField("last", nil, lasts),
+		data.NewField("valid", nil, valids),
+	)
+
+	return frame, nil
+}
+
 // LoadSettings gets the relevant settings from the plugin context
 func LoadSettings(ctx backend.PluginContext) (*DatasourceSettings, error) {
 	model := &DatasourceSettings{}
@@ -90,7 +362,11 @@ func newDatasource() datasource.ServeOpts {
 
 	im := datasource.NewInstanceManager(newDataSourceInstance)
 	ds := &EPICSDatasource{
-		im: im,
+		im:                   im,
+		channelCacheTTL:      defaultChannelCacheTTL,
+		channelCacheEntries:  map[string]*channelCacheEntry{},
+		subscriptions:        newSubscriptionRegistry(subscriptionStatePath()),
+		pvSearchCacheEntries: map[string]*pvSearchCacheEntry{},
 	}
 
 	mux := http.NewServeMux()
@@ -99,11 +375,20 @@ func newDatasource() datasource.ServeOpts {
 	// Bind the HTTP paths to functions that respond to them
 	mux.HandleFunc("/systems", ds.handleResourceChannels)
 	mux.HandleFunc("/channels", ds.handleResourceChannels)
+	mux.HandleFunc("/pvinfo", ds.handleResourcePVInfo)
+	mux.HandleFunc("/search", ds.handleResourceSearch)
+	mux.HandleFunc("/cache/flush", ds.handleResourceCacheFlush)
+	mux.HandleFunc("/resources/subscriptions", ds.handleSubscriptionsCollection)
+	mux.HandleFunc("/resources/subscriptions/", ds.handleSubscriptionItem)
+	mux.HandleFunc("/resources/reconcile", ds.handleReconcile)
+	mux.HandleFunc("/resources/pvs", ds.handleResourcePVsSearch)
+	mux.HandleFunc("/resources/pvs/", ds.handleResourcePVMetadata)
 
 	return datasource.ServeOpts{
 		CallResourceHandler: httpResourceHandler,
 		QueryDataHandler:    ds,
 		CheckHealthHandler:  ds,
+		StreamHandler:       ds,
 	}
 }
 
@@ -114,6 +399,173 @@ type EPICSDatasource struct {
 	// of datasource instances in plugins. It's not a requirements
 	// but a best practice that we recommend that you follow.
 	im instancemgmt.InstanceManager
+
+	// channelCacheTTL, channelCacheEntries, and channelCacheSF back the /systems and /channels
+	// resource routes (and CheckHealth) so a burst of dashboard loads doesn't each re-fetch the
+	// entire PV list from the archiver. See getCachedChannels.
+	channelCacheTTL     time.Duration
+	channelCacheMu      sync.Mutex
+	channelCacheEntries map[string]*channelCacheEntry
+	channelCacheSF      singleflight.Group
+
+	// subscriptions backs the /resources/subscriptions* and /resources/reconcile routes, letting
+	// an external operator manage PV subscriptions declaratively instead of by hand-editing the
+	// datasource JSON.
+	subscriptions *subscriptionRegistry
+
+	// pvSearchCacheMu/pvSearchCacheEntries back the /resources/pvs ETag cache, keyed on
+	// (datasourceUID, query) - see handleResourcePVsSearch.
+	pvSearchCacheMu      sync.Mutex
+	pvSearchCacheEntries map[string]*pvSearchCacheEntry
+}
+
+// defaultChannelCacheTTL is how long a cached channel list is considered fresh before the next
+// request re-fetches it from the archiver.
+const defaultChannelCacheTTL = 60 * time.Second
+
+// maxChannelCacheEntries bounds the cache so a plugin instance juggling many distinct archivers
+// (different server:managePort combos) can't grow this map without limit.
+const maxChannelCacheEntries = 32
+
+type channelCacheEntry struct {
+	channels  []string
+	periods   []float64
+	fetchedAt time.Time
+}
+
+func channelCacheKey(server, manageport string) string {
+	return server + ":" + manageport
+}
+
+// getCachedChannels returns the full PV list for (server, manageport), serving it from cache when
+// younger than channelCacheTTL. Concurrent callers for the same key that miss the cache at the
+// same time are coalesced via singleflight so only one of them actually hits the archiver.
+func (ds *EPICSDatasource) getCachedChannels(server, manageport string) ([]string, []float64, error) {
+	key := channelCacheKey(server, manageport)
+
+	ds.channelCacheMu.Lock()
+	entry, ok := ds.channelCacheEntries[key]
+	ds.channelCacheMu.Unlock()
+
+	if ok && time.Since(entry.fetchedAt) < ds.channelCacheTTL {
+		log.DefaultLogger.Debug(fl() + "channel cache hit for " + key)
+		return entry.channels, entry.periods, nil
+	}
+
+	log.DefaultLogger.Debug(fl() + "channel cache miss for " + key)
+
+	v, err, _ := ds.channelCacheSF.Do(key, func() (interface{}, error) {
+		channels, periods, err, message := ds.GetArchiverChannels(server, manageport, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", message, err)
+		}
+		return &channelCacheEntry{channels: channels, periods: periods, fetchedAt: time.Now()}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fresh := v.(*channelCacheEntry)
+
+	ds.channelCacheMu.Lock()
+	if len(ds.channelCacheEntries) >= maxChannelCacheEntries {
+		ds.evictOldestChannelCacheEntryLocked()
+	}
+	ds.channelCacheEntries[key] = fresh
+	ds.channelCacheMu.Unlock()
+
+	return fresh.channels, fresh.periods, nil
+}
+
+// evictOldestChannelCacheEntryLocked drops the least-recently-fetched entry. Callers must hold
+// channelCacheMu.
+func (ds *EPICSDatasource) evictOldestChannelCacheEntryLocked() {
+	var oldestKey string
+	var oldestAt time.Time
+
+	for key, entry := range ds.channelCacheEntries {
+		if oldestKey == "" || entry.fetchedAt.Before(oldestAt) {
+			oldestKey = key
+			oldestAt = entry.fetchedAt
+		}
+	}
+
+	if oldestKey != "" {
+		delete(ds.channelCacheEntries, oldestKey)
+	}
+}
+
+// flushChannelCache clears every cached channel list, forcing the next /systems, /channels, or
+// CheckHealth call to re-fetch from the archiver.
+func (ds *EPICSDatasource) flushChannelCache() {
+	ds.channelCacheMu.Lock()
+	defer ds.channelCacheMu.Unlock()
+
+	n := len(ds.channelCacheEntries)
+	ds.channelCacheEntries = map[string]*channelCacheEntry{}
+	log.DefaultLogger.Debug(fmt.Sprintf(fl()+"flushed channel cache (%d entries)", n))
+}
+
+// queryWorkerPoolMax bounds how many queries within a single QueryData request are fetched from
+// the archiver concurrently.
+const queryWorkerPoolMax = 8
+
+// Retry/backoff tuning for archiver requests, mirroring the shape of grpc-go's default
+// connection backoff: an exponential delay with a cap, plus jitter to avoid a thundering herd.
+const (
+	retryBaseDelay   = 250 * time.Millisecond
+	retryFactor      = 1.6
+	retryMaxDelay    = 5 * time.Second
+	retryMaxAttempts = 3
+	retryJitterFrac  = 0.2
+)
+
+// jitter returns d adjusted by up to +/-retryJitterFrac, picked uniformly at random.
+func jitter(d time.Duration) time.Duration {
+	delta := (rand.Float64()*2 - 1) * retryJitterFrac
+	return time.Duration(float64(d) * (1 + delta))
+}
+
+// doWithRetry issues req via client, retrying on transport errors and 5xx responses with
+// exponential backoff and jitter. It gives up early if ctx is cancelled, and never retries a
+// non-5xx response (e.g. a 4xx from a bad PV name is not transient).
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	delay := retryBaseDelay
+	var lastErr error
+
+	for attempt := 0; attempt <= retryMaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(jitter(delay)):
+			}
+
+			delay = time.Duration(float64(delay) * retryFactor)
+			if delay > retryMaxDelay {
+				delay = retryMaxDelay
+			}
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		if res.StatusCode >= http.StatusInternalServerError {
+			res.Body.Close()
+			lastErr = fmt.Errorf("archiver returned %s", res.Status)
+			continue
+		}
+
+		return res, nil
+	}
+
+	return nil, lastErr
 }
 
 // QueryData handles multiple queries and returns multiple responses.
@@ -133,13 +585,51 @@ func (ds *EPICSDatasource) QueryData(ctx context.Context, req *backend.QueryData
 		return nil, err
 	}
 
-	// loop over queries and execute them individually.
-	for _, q := range req.Queries {
-		res := ds.query(ctx, q, config.Server, config.ManagePort, config.DataPort)
+	// Fan the queries out across a small worker pool so a dashboard with many panels doesn't pay
+	// for each archiver round-trip serially. Pool size is capped so we don't overwhelm the
+	// archiver when a single dashboard has dozens of queries.
+	numWorkers := len(req.Queries)
+	if numWorkers > queryWorkerPoolMax {
+		numWorkers = queryWorkerPoolMax
+	}
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	type queryResult struct {
+		refID string
+		res   backend.DataResponse
+	}
+
+	jobs := make(chan backend.DataQuery)
+	results := make(chan queryResult, len(req.Queries))
+
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range jobs {
+				results <- queryResult{refID: q.RefID, res: ds.query(ctx, req.PluginContext, q, config.Server, config.ManagePort, config.DataPort)}
+			}
+		}()
+	}
+
+	go func() {
+		for _, q := range req.Queries {
+			jobs <- q
+		}
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-		// save the response in a hashmap
-		// based on with RefID as identifier
-		response.Responses[q.RefID] = res
+	// save each response in a hashmap based on RefID as identifier
+	for r := range results {
+		response.Responses[r.refID] = r.res
 	}
 
 	return response, nil
@@ -152,6 +642,7 @@ type queryModel struct {
 	QueryText      string `json:"queryText"`
 	UnitConversion int    `json:"unitConversion"`
 	Transform      int    `json:"transform"`
+	Aggregation    int    `json:"aggregation"`
 	DisableBinning bool   `json:"disablebinning"`
 	IntervalMs     int    `json:"intervalMs"`
 	MaxDataPoints  int    `json:"maxDataPoints"`
@@ -189,7 +680,7 @@ type PVStringData []struct {
 	} `json:"meta"`
 }
 
-func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, server string, manageport string, dataport string) backend.DataResponse {
+func (ds *EPICSDatasource) query(ctx context.Context, pluginCtx backend.PluginContext, query backend.DataQuery, server string, manageport string, dataport string) backend.DataResponse {
 
 	// Unmarshal the json into our queryModel
 	var qm queryModel
@@ -242,12 +733,19 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 
 	var sampleRate int64
 
+	// clientBin indicates the requested range is too short for the archiver's 1-second-or-coarser
+	// binning to help, so we fetch the raw data and bin it ourselves below. bucketMs is sized the
+	// same way the archiver-side binsize is: enough buckets to cover the range in MaxDataPoints.
+	var clientBin bool
+	var bucketMs int64
+
 	// Do our own binning if we have to, for now just return the raw data and let the browser deal with it
 	if qm.DisableBinning {
 		sampleRate = 0
 	} else if binsize < 1 {
-		// TODO - This is where we will bin it ourselves
 		sampleRate = 0
+		clientBin = true
+		bucketMs = int64(math.Ceil(querylength * 1000 / float64(query.MaxDataPoints)))
 	} else {
 		// Else tell the archiver to do it for us
 		sampleRate = int64(binsize)
@@ -271,22 +769,34 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 	getdataurl := fmt.Sprintf("http://%s:%s/retrieval/data/getData.json?%s", server, dataport, params.Encode())
 	log.DefaultLogger.Debug(fmt.Sprintf("Archiver URL = %s", getdataurl))
 
-	// Give the archiver 1 minute to reply
-	client := http.Client{Timeout: time.Second * 60}
-
-	httpreq, err := http.NewRequest(http.MethodGet, getdataurl, nil)
+	httpreq, err := http.NewRequestWithContext(ctx, http.MethodGet, getdataurl, nil)
 	if err != nil {
+		response.Frames = append(response.Frames, empty_frame)
+		response.Error = err
+		return response
 	}
 
-	// Retrieve the channel data
-	res, err := client.Do(httpreq)
+	httpClient, err := ds.getHTTPClient(ctx, pluginCtx)
 	if err != nil {
-		// Send back an empty frame, the query failed in some way
 		response.Frames = append(response.Frames, empty_frame)
 		response.Error = err
 		return response
 	}
 
+	// Retrieve the channel data. doWithRetry honors ctx cancellation (e.g. the panel was closed
+	// or the query superseded) and retries transient 5xx/timeout failures with backoff.
+	res, err := doWithRetry(ctx, httpClient, httpreq)
+	if cerr := classifyArchiverError(res, err); cerr != nil {
+		if res != nil {
+			res.Body.Close()
+		}
+		// Send back an empty frame, the query failed in some way
+		response.Frames = append(response.Frames, empty_frame)
+		response.Error = cerr
+		return response
+	}
+	defer res.Body.Close()
+
 	// Pull the body out of the response
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -377,6 +887,8 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 	// Store times and values here before building the response
 	times := make([]time.Time, count)
 	values := make([]float64, count)
+	severities := make([]int64, count)
+	statuses := make([]int64, count)
 
 	// Temporary variables for conversions/transforms
 	var val float64
@@ -386,49 +898,18 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 		for _, pvdatarow := range pvdataset.Data {
 
 			// If we are doing a unit conversion, perform it now while we have the single value in hand
-			switch qm.UnitConversion {
-
-			case UNIT_CONVERT_NONE:
-				// No conversion, just assign it straight over
-				val = pvdatarow.Val
-
-			case UNIT_CONVERT_DEG_TO_RAD:
-				// RAD = DEG * π/180  (1° = 0.01745rad)
-				val = pvdatarow.Val * (math.Pi / 180)
-
-			case UNIT_CONVERT_RAD_TO_DEG:
-				// DEG = RAD * 180/π  (1rad = 57.296°)
-				val = pvdatarow.Val * (180 / math.Pi)
-
-			case UNIT_CONVERT_RAD_TO_ARCSEC:
-				// ARCSEC = RAD * (3600 * 180)/π  (1rad = 206264.806")
-				val = pvdatarow.Val * (3600 * 180 / math.Pi)
-
-			case UNIT_CONVERT_K_TO_C:
-				// °C = K + 273.15
-				val = pvdatarow.Val + 273.15
-
-			case UNIT_CONVERT_C_TO_K:
-				// K = °C − 273.15
-				val = pvdatarow.Val - 273.15
-
-			case UNIT_CONVERT_F_TO_C:
-				// °C = (°F − 32) × 5⁄9
-				val = (pvdatarow.Val - 32) * 5 / 9
-
-			case UNIT_CONVERT_C_TO_F:
-				// °F = (°C * 9/5) + 32
-				val = (pvdatarow.Val * 9 / 5) + 32
-
-			default:
+			val, err = convertUnit(pvdatarow.Val, qm.UnitConversion)
+			if err != nil {
 				// Send back an empty frame with an error, we did not understand the conversion
 				response.Frames = append(response.Frames, empty_frame)
-				response.Error = fmt.Errorf("Unknown unit conversion: %d", qm.UnitConversion)
+				response.Error = err
 				return response
 			}
 
 			// Assign to the frame
 			values[i] = val
+			severities[i] = pvdatarow.Severity
+			statuses[i] = pvdatarow.Status
 
 			// One of the transforms is to remove the fractional seconds from the timestamps.  Used when computing
 			// differences between two channels.  This will force the timestamps to line up.  Only works well with
@@ -443,6 +924,21 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 		}
 	}
 
+	// Sub-second ranges are too fine-grained for the archiver's own binning, so we build the
+	// aggregate frame ourselves and skip the derivative/delta transforms below, which assume a
+	// plain value-per-sample series.
+	if clientBin {
+		frame, err := buildAggregateFrame(qm, query.TimeRange.From, bucketMs, times, values, severities)
+		if err != nil {
+			response.Frames = append(response.Frames, empty_frame)
+			response.Error = err
+			return response
+		}
+
+		response.Frames = append(response.Frames, frame)
+		return response
+	}
+
 	// Perform any requested data transforms
 	switch qm.Transform {
 
@@ -475,9 +971,11 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 			dvalues[i-1] = dvdt
 		}
 
-		// Reassign the original arrays to be the 1st derivative results
+		// Severity/status carry across unchanged, just shifted by one like the time/value arrays.
 		times = dtimes
 		values = dvalues
+		severities = severities[1:]
+		statuses = statuses[1:]
 
 	case TRANSFORM_DELTA:
 		// Compute the deltas of the data.  This algorithm replicates what numpy diff() does in Python,
@@ -498,6 +996,8 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 		// Reassign the original arrays to be the new results
 		times = dtimes
 		values = dvalues
+		severities = severities[1:]
+		statuses = statuses[1:]
 
 	case TRANSFORM_TRUNCATE_FRAC_SECS:
 		// Nothing to do here, this would have been handled up above when the nanoseconds were dropped in the time creation
@@ -522,6 +1022,11 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 	frame.Fields = append(frame.Fields, data.NewField("Value", nil, values))
 	frame.Fields = append(frame.Fields, data.NewField("Time", nil, times))
 
+	severityField := data.NewField("Severity", nil, toUint8Slice(severities))
+	severityField.SetConfig(epicsSeverityFieldConfig())
+	frame.Fields = append(frame.Fields, severityField)
+	frame.Fields = append(frame.Fields, data.NewField("Status", nil, toUint8Slice(statuses)))
+
 	// add the frames to the response
 	response.Frames = append(response.Frames, frame)
 
@@ -529,7 +1034,226 @@ func (ds *EPICSDatasource) query(ctx context.Context, query backend.DataQuery, s
 
 }
 
-type GetPVStatus []struct {
+// streamPathPrefix is the plugin-relative prefix for live PV channels. Grafana Live addresses these
+// as "ds/<uid>/pv/<channel>"; by the time a request reaches the plugin, Path has already had the
+// "ds/<uid>/" portion stripped, so we only ever see "pv/<channel>[?unitConversion=N]".
+const streamPathPrefix = "pv/"
+
+// streamPollInterval is how often RunStream re-checks the archiver for a new sample. A real EPICS
+// CA/PVA monitor (or the archiver's own websocket pubsub endpoint) would push the instant a value
+// changes with no per-PV archiver round-trip; this plugin has no CA/PVA client library available
+// to it, so RunStream falls back to polling getData.json instead. This is an explicitly scoped-
+// down substitute, not "live streaming" in the CA/PVA sense - see consecutiveFailures/
+// streamFailureLogInterval in RunStream for how a sustained archiver outage is kept from flooding
+// logs at this cadence.
+const streamPollInterval = 1 * time.Second
+
+// streamFailureLogInterval caps how often RunStream logs a stream poll failure once
+// consecutiveFailures is past the first one, so an outage lasting minutes doesn't produce a Warn
+// every single tick for as long as the panel stays open.
+const streamFailureLogInterval = 30
+
+// streamLookback bounds how far back each poll asks the archiver to look for a fresh sample.
+const streamLookback = 10 * time.Second
+
+// parseStreamChannel splits a stream path into the PV name and the unitConversion it was
+// subscribed with, mirroring queryModel.UnitConversion for historical queries.
+func parseStreamChannel(path string) (channel string, unitConversion int, err error) {
+	if !strings.HasPrefix(path, streamPathPrefix) {
+		return "", 0, fmt.Errorf("unsupported stream path: %s", path)
+	}
+
+	rest := strings.TrimPrefix(path, streamPathPrefix)
+
+	channel = rest
+	if idx := strings.IndexByte(rest, '?'); idx >= 0 {
+		channel = rest[:idx]
+
+		params, perr := url.ParseQuery(rest[idx+1:])
+		if perr != nil {
+			return "", 0, perr
+		}
+
+		if raw := params.Get("unitConversion"); raw != "" {
+			var uc int
+			if _, serr := fmt.Sscanf(raw, "%d", &uc); serr != nil {
+				return "", 0, fmt.Errorf("bad unitConversion %q: %s", raw, serr.Error())
+			}
+			unitConversion = uc
+		}
+	}
+
+	if channel == "" {
+		return "", 0, fmt.Errorf("stream path is missing a PV name: %s", path)
+	}
+
+	return channel, unitConversion, nil
+}
+
+// SubscribeStream is called when a panel subscribes to a "pv/<channel>" Grafana Live channel.
+func (ds *EPICSDatasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if _, _, err := parseStreamChannel(req.Path); err != nil {
+		log.DefaultLogger.Debug(fl() + "rejecting subscribe: " + err.Error())
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream is part of the backend.StreamHandler interface. Dashboards only ever consume PV
+// data, so publishing back into a channel from the frontend is not supported.
+func (ds *EPICSDatasource) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream is called once per subscribed channel and should block, pushing a frame via sender
+// every time the PV updates, until ctx is cancelled (the last subscriber disconnects).
+func (ds *EPICSDatasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	channel, unitConversion, err := parseStreamChannel(req.Path)
+	if err != nil {
+		return err
+	}
+
+	config, err := LoadSettings(req.PluginContext)
+	if err != nil {
+		return err
+	}
+
+	log.DefaultLogger.Info(fl() + "starting PV stream for " + channel)
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	var lastSecs, lastNanos int64
+
+	// consecutiveFailures drives streamFailureLogInterval below: during a sustained archiver
+	// outage we'd otherwise log a Warn every tick (once a second) for as long as the dashboard
+	// stays open.
+	var consecutiveFailures int
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.DefaultLogger.Info(fl() + "stopping PV stream for " + channel)
+			return nil
+
+		case <-ticker.C:
+			frame, secs, nanos, err := ds.pollStreamSample(ctx, req.PluginContext, config, channel, unitConversion, lastSecs, lastNanos)
+			if err != nil {
+				consecutiveFailures++
+				if consecutiveFailures == 1 || consecutiveFailures%streamFailureLogInterval == 0 {
+					log.DefaultLogger.Warn(fmt.Sprintf(fl()+"stream poll failed for %s (failure %d): %s", channel, consecutiveFailures, err.Error()))
+				}
+				continue
+			}
+			consecutiveFailures = 0
+
+			if frame == nil {
+				// No sample newer than the last one we sent.
+				continue
+			}
+
+			lastSecs, lastNanos = secs, nanos
+
+			if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pollStreamSample fetches the most recent archiver sample for channel and, if it is newer than
+// (afterSecs, afterNanos), returns a single-row frame carrying time/value/severity/status. It
+// returns a nil frame (and no error) when there is nothing new to send.
+func (ds *EPICSDatasource) pollStreamSample(ctx context.Context, pluginCtx backend.PluginContext, config *DatasourceSettings, channel string, unitConversion int, afterSecs, afterNanos int64) (*data.Frame, int64, int64, error) {
+	now := time.Now()
+
+	params := url.Values{}
+	params.Add("from", now.Add(-streamLookback).Format(time.RFC3339Nano))
+	params.Add("to", now.Format(time.RFC3339Nano))
+	params.Add("pv", channel)
+
+	getdataurl := fmt.Sprintf("http://%s:%s/retrieval/data/getData.json?%s", config.Server, config.DataPort, params.Encode())
+
+	httpreq, err := http.NewRequestWithContext(ctx, http.MethodGet, getdataurl, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	httpClient, err := ds.getHTTPClient(ctx, pluginCtx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	res, err := doWithRetry(ctx, httpClient, httpreq)
+	if cerr := classifyArchiverError(res, err); cerr != nil {
+		if res != nil {
+			res.Body.Close()
+		}
+		return nil, 0, 0, cerr
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	body = bytes.Replace(body, []byte(": NaN"), []byte(": null"), -1)
+
+	var pvdata PVData
+	if err := json.Unmarshal(body, &pvdata); err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Find the latest sample across the (usually single) dataset the archiver returned.
+	var found bool
+	var latest struct {
+		Nanos    int64
+		Secs     int64
+		Severity int64
+		Status   int64
+		Val      float64
+	}
+
+	for _, pvdataset := range pvdata {
+		for _, row := range pvdataset.Data {
+			if row.Secs < afterSecs || (row.Secs == afterSecs && row.Nanos <= afterNanos) {
+				continue
+			}
+			if !found || row.Secs > latest.Secs || (row.Secs == latest.Secs && row.Nanos > latest.Nanos) {
+				found = true
+				latest.Nanos = row.Nanos
+				latest.Secs = row.Secs
+				latest.Severity = row.Severity
+				latest.Status = row.Status
+				latest.Val = row.Val
+			}
+		}
+	}
+
+	if !found {
+		return nil, afterSecs, afterNanos, nil
+	}
+
+	val, err := convertUnit(latest.Val, unitConversion)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, []time.Time{time.Unix(latest.Secs, latest.Nanos)}),
+		data.NewField("value", nil, []float64{val}),
+		data.NewField("severity", nil, []int64{latest.Severity}),
+		data.NewField("status", nil, []int64{latest.Status}),
+	)
+
+	return frame, latest.Secs, latest.Nanos, nil
+}
+
+// PVStatusEntry is a single row of the archiver's getPVStatus response - named (rather than
+// inlined into GetPVStatus) so /pvinfo can hand a single entry back to the caller.
+type PVStatusEntry struct {
 	Appliance                  string  `json:"appliance"`
 	ConnectionFirstEstablished string  `json:"connectionFirstEstablished"`
 	ConnectionLastRestablished string  `json:"connectionLastRestablished"`
@@ -544,6 +1268,8 @@ type GetPVStatus []struct {
 	Status                     string  `json:"status"`
 }
 
+type GetPVStatus []PVStatusEntry
+
 func (ds *EPICSDatasource) GetArchiverChannels(Server string, ManagePort string, SingleChannel string) ([]string, []float64, error, string) {
 
 	// Init a container for the raw pv list
@@ -602,40 +1328,213 @@ func (ds *EPICSDatasource) GetArchiverChannels(Server string, ManagePort string,
 	return channels, periods, nil, ""
 }
 
-// CheckHealth handles health checks sent from Grafana to the plugin.
-// The main use case for these health checks is the test button on the
-// datasource configuration page which allows users to verify that
-// a datasource is working as expected.
-func (ds *EPICSDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	var status = backend.HealthStatusOk
-	var message = "Data source is working"
+// GetPVStatusDetail fetches the full getPVStatus record for a single PV, for the /pvinfo resource
+// route. Unlike GetArchiverChannels (which flattens the list down to names+periods), this hands
+// back every field the archiver reports so the frontend can render a health badge.
+func (ds *EPICSDatasource) GetPVStatusDetail(ctx context.Context, pluginCtx backend.PluginContext, server string, manageport string, pv string) (*PVStatusEntry, error) {
+	params := url.Values{}
+	params.Add("pv", pv)
 
-	config, err := LoadSettings(req.PluginContext)
+	getpvurl := fmt.Sprintf("http://%s:%s/mgmt/bpl/getPVStatus?%s", server, manageport, params.Encode())
+
+	httpreq, err := http.NewRequestWithContext(ctx, http.MethodGet, getpvurl, nil)
 	if err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: "Invalid config",
-		}, nil
+		return nil, err
+	}
+
+	httpClient, err := ds.getHTTPClient(ctx, pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(httpreq)
+	if cerr := classifyArchiverError(res, err); cerr != nil {
+		if res != nil {
+			res.Body.Close()
+		}
+		return nil, cerr
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var pvs GetPVStatus
+	if err := json.Unmarshal(body, &pvs); err != nil {
+		return nil, err
+	}
+
+	if len(pvs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPVNotFound, pv)
+	}
+
+	return &pvs[0], nil
+}
+
+// searchDefaultLimit and searchMaxLimit bound how many matches /search asks the archiver for, so
+// an editor keystroke against a site with hundreds of thousands of PVs can't trigger an
+// unbounded response.
+const (
+	searchDefaultLimit = 100
+	searchMaxLimit     = 5000
+)
+
+// SearchPVs proxies the archiver's getMatchingPVs endpoint, which accepts a glob-style pattern
+// (e.g. "k1:dcs:*:az") and returns up to limit matching PV names directly from the archiver's own
+// index, instead of pulling the entire PV list into the plugin and filtering in memory.
+func (ds *EPICSDatasource) SearchPVs(ctx context.Context, pluginCtx backend.PluginContext, server string, manageport string, glob string, limit int) ([]string, error) {
+	params := url.Values{}
+	params.Add("pv", glob)
+	params.Add("limit", strconv.Itoa(limit))
+
+	searchurl := fmt.Sprintf("http://%s:%s/mgmt/bpl/getMatchingPVs?%s", server, manageport, params.Encode())
+
+	httpreq, err := http.NewRequestWithContext(ctx, http.MethodGet, searchurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := ds.getHTTPClient(ctx, pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(httpreq)
+	if cerr := classifyArchiverError(res, err); cerr != nil {
+		if res != nil {
+			res.Body.Close()
+		}
+		return nil, cerr
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	if err := json.Unmarshal(body, &matches); err != nil {
+		return nil, err
 	}
 
-	// Get the channels as a test of the archiver connection
-	var channels []string
-	channels, _, err, message = ds.GetArchiverChannels(config.Server, config.ManagePort, "")
+	return matches, nil
+}
+
+// ApplianceInfo is the (trimmed) shape of the archiver's getApplianceInfo response, used to
+// confirm we're actually talking to an archiver appliance and to surface its identity/version.
+type ApplianceInfo struct {
+	Identity string `json:"identity"`
+	Version  string `json:"version"`
+}
+
+// probeApplianceInfo issues a lightweight GET against the management API's getApplianceInfo
+// route, which is cheap enough to call on every "Save & Test" click without stressing the
+// archiver the way a full channel list fetch would.
+func (ds *EPICSDatasource) probeApplianceInfo(ctx context.Context, pluginCtx backend.PluginContext, server string, manageport string) (*ApplianceInfo, error) {
+	probeurl := fmt.Sprintf("http://%s:%s/mgmt/bpl/getApplianceInfo", server, manageport)
+
+	httpreq, err := http.NewRequestWithContext(ctx, http.MethodGet, probeurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := ds.getHTTPClient(ctx, pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(httpreq)
+	if err != nil {
+		// DNS failures, connection refused, and TLS handshake errors all surface here.
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: getApplianceInfo returned %s", ErrArchiverUnavailable, res.Status)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var info ApplianceInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("unexpected response shape from getApplianceInfo: %w", err)
+	}
+
+	return &info, nil
+}
+
+// classifyHealthError turns a probeApplianceInfo failure into a HealthStatus and a message that
+// distinguishes the common failure modes (DNS, TLS, non-2xx, unparseable body) instead of a flat
+// "it didn't work".
+func classifyHealthError(err error) (backend.HealthStatus, string) {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return backend.HealthStatusError, "DNS lookup failed for archiver host: " + dnsErr.Error()
+	}
+
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &unknownAuthErr) || errors.As(err, &hostnameErr) {
+		return backend.HealthStatusError, "TLS certificate error talking to archiver: " + err.Error()
+	}
+
+	if strings.Contains(err.Error(), "unexpected response shape") {
+		return backend.HealthStatusUnknown, "Archiver responded, but not with the expected JSON shape: " + err.Error()
+	}
+
+	if errors.Is(err, ErrArchiverUnavailable) {
+		return backend.HealthStatusError, err.Error()
+	}
+
+	return backend.HealthStatusError, "Failure reaching archiver: " + err.Error()
+}
 
+// CheckHealth handles health checks sent from Grafana to the plugin.
+// The main use case for these health checks is the test button on the
+// datasource configuration page which allows users to verify that
+// a datasource is working as expected.
+func (ds *EPICSDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	config, err := LoadSettings(req.PluginContext)
 	if err != nil {
 		return &backend.CheckHealthResult{
 			Status:  backend.HealthStatusError,
-			Message: "Failure to get channels: " + message,
+			Message: "Invalid config: " + err.Error(),
 		}, nil
+	}
 
-	} else {
-		// Confirmation success back to the user
-		message = fmt.Sprintf("Connection confirmed to %s:%s, found %d PVs", config.Server, config.ManagePort, len(channels))
+	info, err := ds.probeApplianceInfo(ctx, req.PluginContext, config.Server, config.ManagePort)
+	if err != nil {
+		status, message := classifyHealthError(err)
+		return &backend.CheckHealthResult{Status: status, Message: message}, nil
+	}
+
+	// The channel count is a nice-to-have for the success message, not load-bearing for the
+	// health verdict itself, so don't fail the whole check if this particular call has a hiccup.
+	var pvCount int
+	if channels, _, cerr := ds.getCachedChannels(config.Server, config.ManagePort); cerr == nil {
+		pvCount = len(channels)
+	}
+
+	details, err := json.Marshal(map[string]interface{}{
+		"applianceIdentity": info.Identity,
+		"applianceVersion":  info.Version,
+		"pvCount":           pvCount,
+	})
+	if err != nil {
+		details = nil
 	}
 
 	return &backend.CheckHealthResult{
-		Status:  status,
-		Message: message,
+		Status:      backend.HealthStatusOk,
+		Message:     fmt.Sprintf("Connection confirmed to %s (%s), found %d PVs", config.Server, info.Identity, pvCount),
+		JSONDetails: details,
 	}, nil
 }
 
@@ -689,13 +1588,11 @@ func (ds *EPICSDatasource) handleResourceChannels(rw http.ResponseWriter, req *h
 		}
 		system := params.Get("system")
 
-		// Get the channels list fresh from the archiver (again)
-		var allchannels []string
-		var message string
-		allchannels, _, err, message = ds.GetArchiverChannels(config.Server, config.ManagePort, "")
+		// Get the channels list, served from cache when fresh
+		allchannels, _, err := ds.getCachedChannels(config.Server, config.ManagePort)
 
 		if err != nil {
-			log.DefaultLogger.Error(fl() + "channels retrieve error: " + message)
+			log.DefaultLogger.Error(fl() + "channels retrieve error: " + err.Error())
 			writeResult(rw, "?", nil, err)
 			return
 		}
@@ -718,13 +1615,11 @@ func (ds *EPICSDatasource) handleResourceChannels(rw http.ResponseWriter, req *h
 	} else if strings.HasPrefix(req.URL.String(), "/systems") {
 		// Create a systems list based on the list of channels
 
-		// Get the channels list fresh from the archiver
-		var channels []string
-		var message string
-		channels, _, err, message = ds.GetArchiverChannels(config.Server, config.ManagePort, "")
+		// Get the channels list, served from cache when fresh
+		channels, _, err := ds.getCachedChannels(config.Server, config.ManagePort)
 
 		if err != nil {
-			log.DefaultLogger.Error(fl() + "systems retrieve error: " + message)
+			log.DefaultLogger.Error(fl() + "systems retrieve error: " + err.Error())
 			writeResult(rw, "?", nil, err)
 			return
 		}
@@ -761,17 +1656,615 @@ func (ds *EPICSDatasource) handleResourceChannels(rw http.ResponseWriter, req *h
 	}
 }
 
+// handleResourcePVInfo backs the /pvinfo resource route. It returns the archiver's full
+// getPVStatus record for a single PV (appliance, connectionState, samplingPeriod, lastEvent, ...)
+// so the QueryEditor can show a health badge next to the PV picker.
+func (ds *EPICSDatasource) handleResourcePVInfo(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := req.Context()
+	pluginCtx := httpadapter.PluginConfigFromContext(ctx)
+	config, err := LoadSettings(pluginCtx)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "settings load error")
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	pv := req.URL.Query().Get("pv")
+	if pv == "" {
+		writeResult(rw, "?", nil, fmt.Errorf("missing required 'pv' query parameter"))
+		return
+	}
+
+	status, err := ds.GetPVStatusDetail(ctx, pluginCtx, config.Server, config.ManagePort, pv)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "pvinfo retrieve error: " + err.Error())
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	writeResult(rw, "pvinfo", status, nil)
+}
+
+// handleResourceSearch backs the /search resource route. Unlike handleResourceChannels, which
+// loads the entire PV list into memory on every keystroke, this proxies straight through to the
+// archiver's own getMatchingPVs glob search so it scales to sites with huge PV counts.
+func (ds *EPICSDatasource) handleResourceSearch(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodGet {
+		return
+	}
+
+	ctx := req.Context()
+	pluginCtx := httpadapter.PluginConfigFromContext(ctx)
+	config, err := LoadSettings(pluginCtx)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "settings load error")
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	params := req.URL.Query()
+	query := params.Get("q")
+
+	limit := searchDefaultLimit
+	if raw := params.Get("limit"); raw != "" {
+		if parsed, perr := strconv.Atoi(raw); perr == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+
+	matches, err := ds.SearchPVs(ctx, pluginCtx, config.Server, config.ManagePort, query, limit)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "search error: " + err.Error())
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	writeResult(rw, "matches", matches, nil)
+}
+
+// handleResourceCacheFlush backs POST /cache/flush, letting an operator force the next
+// /systems, /channels, or CheckHealth call to bypass the channel cache and re-hit the archiver -
+// useful right after PVs are added to or removed from the archiver appliance.
+func (ds *EPICSDatasource) handleResourceCacheFlush(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodPost {
+		return
+	}
+
+	ds.flushChannelCache()
+	writeResult(rw, "flushed", true, nil)
+}
+
+// Subscription is the declarative unit an operator manages via /resources/subscriptions* and
+// /resources/reconcile: one Archiver endpoint + PV glob a controller wants kept in sync.
+type Subscription struct {
+	Name         string   `json:"name"`
+	ApplianceURL string   `json:"applianceURL"`
+	PVGlob       string   `json:"pvGlob"`
+	Retention    string   `json:"retention,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+func validateSubscription(s Subscription) error {
+	if s.Name == "" {
+		return fmt.Errorf("subscription name is required")
+	}
+	if s.ApplianceURL == "" {
+		return fmt.Errorf("applianceURL is required")
+	}
+	if s.PVGlob == "" {
+		return fmt.Errorf("pvGlob is required")
+	}
+	return nil
+}
+
+// subscriptionStateEnvVar points at the plugin's data directory, where Grafana lets a backend
+// plugin persist state across restarts.
+const subscriptionStateEnvVar = "GF_PLUGIN_DATA_PATH"
+const subscriptionStateFileName = "subscriptions.json"
+
+func subscriptionStatePath() string {
+	dir := os.Getenv(subscriptionStateEnvVar)
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Join(dir, subscriptionStateFileName)
+}
+
+// subscriptionRegistry is the in-memory store of Subscriptions, mirrored to disk on every
+// mutation so a plugin restart converges back to the same state instead of losing it.
+type subscriptionRegistry struct {
+	mu   sync.Mutex
+	path string
+	subs map[string]Subscription
+}
+
+func newSubscriptionRegistry(path string) *subscriptionRegistry {
+	r := &subscriptionRegistry{path: path, subs: map[string]Subscription{}}
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		// Missing file on first run is expected, not an error worth logging.
+		return r
+	}
+
+	if err := json.Unmarshal(body, &r.subs); err != nil {
+		log.DefaultLogger.Warn(fl() + "failed to parse persisted subscriptions, starting empty: " + err.Error())
+		r.subs = map[string]Subscription{}
+	}
+
+	return r
+}
+
+func (r *subscriptionRegistry) saveLocked() error {
+	body, err := json.MarshalIndent(r.subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, body, 0644)
+}
+
+func (r *subscriptionRegistry) Get(name string) (Subscription, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.subs[name]
+	return s, ok
+}
+
+func (r *subscriptionRegistry) List() []Subscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Subscription, 0, len(r.subs))
+	for _, s := range r.subs {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (r *subscriptionRegistry) Put(s Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs[s.Name] = s
+	return r.saveLocked()
+}
+
+// Delete removes name, reporting whether it was present. It is a no-op (not an error) to delete a
+// name that doesn't exist, so reconcile stays idempotent.
+func (r *subscriptionRegistry) Delete(name string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.subs[name]; !ok {
+		return false, nil
+	}
+	delete(r.subs, name)
+	return true, r.saveLocked()
+}
+
+// ReconcileStatus mirrors the status a Kubernetes-style controller loop would key its requeue
+// logic on.
+type ReconcileStatus string
+
+const (
+	ReconcileApplied   ReconcileStatus = "Applied"
+	ReconcileUnchanged ReconcileStatus = "Unchanged"
+	ReconcileFailed    ReconcileStatus = "Failed"
+)
+
+// ReconcileResult reports what happened to a single named subscription during one reconcile pass.
+type ReconcileResult struct {
+	Name   string          `json:"name"`
+	Action string          `json:"action"` // "create", "update", "delete", or "none"
+	Status ReconcileStatus `json:"status"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// reconcileSubscriptions diffs desired against the current registry and applies the difference:
+// missing subscriptions are created, changed ones are updated, and registry entries absent from
+// desired are deleted. It is idempotent - reconciling the same desired state twice in a row
+// reports Unchanged the second time - so a controller loop can call it on every resync.
+func (ds *EPICSDatasource) reconcileSubscriptions(desired []Subscription) []ReconcileResult {
+	var results []ReconcileResult
+	desiredNames := map[string]bool{}
+
+	for _, d := range desired {
+		desiredNames[d.Name] = true
+
+		existing, ok := ds.subscriptions.Get(d.Name)
+
+		switch {
+		case !ok:
+			if err := ds.subscriptions.Put(d); err != nil {
+				results = append(results, ReconcileResult{Name: d.Name, Action: "create", Status: ReconcileFailed, Error: err.Error()})
+			} else {
+				results = append(results, ReconcileResult{Name: d.Name, Action: "create", Status: ReconcileApplied})
+			}
+
+		case reflect.DeepEqual(existing, d):
+			results = append(results, ReconcileResult{Name: d.Name, Action: "none", Status: ReconcileUnchanged})
+
+		default:
+			if err := ds.subscriptions.Put(d); err != nil {
+				results = append(results, ReconcileResult{Name: d.Name, Action: "update", Status: ReconcileFailed, Error: err.Error()})
+			} else {
+				results = append(results, ReconcileResult{Name: d.Name, Action: "update", Status: ReconcileApplied})
+			}
+		}
+	}
+
+	for _, existing := range ds.subscriptions.List() {
+		if desiredNames[existing.Name] {
+			continue
+		}
+
+		deleted, err := ds.subscriptions.Delete(existing.Name)
+		switch {
+		case err != nil:
+			results = append(results, ReconcileResult{Name: existing.Name, Action: "delete", Status: ReconcileFailed, Error: err.Error()})
+		case deleted:
+			results = append(results, ReconcileResult{Name: existing.Name, Action: "delete", Status: ReconcileApplied})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+// handleSubscriptionsCollection backs GET /resources/subscriptions, listing every subscription
+// currently in the registry.
+func (ds *EPICSDatasource) handleSubscriptionsCollection(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeResult(rw, "subscriptions", ds.subscriptions.List(), nil)
+}
+
+// handleSubscriptionItem backs GET/POST/PUT/DELETE /resources/subscriptions/:name, letting an
+// operator (or the /resources/reconcile caller) manage a single named subscription.
+func (ds *EPICSDatasource) handleSubscriptionItem(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	name := strings.TrimPrefix(req.URL.Path, "/resources/subscriptions/")
+	if name == "" {
+		writeResult(rw, "?", nil, fmt.Errorf("subscription name is required in the path"))
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		sub, ok := ds.subscriptions.Get(name)
+		if !ok {
+			writeResult(rw, "?", nil, fmt.Errorf("%w: subscription %s", ErrPVNotFound, name))
+			return
+		}
+		writeResult(rw, "subscription", sub, nil)
+
+	case http.MethodPost, http.MethodPut:
+		var sub Subscription
+		if err := json.NewDecoder(req.Body).Decode(&sub); err != nil {
+			writeResult(rw, "?", nil, err)
+			return
+		}
+		sub.Name = name
+
+		if err := validateSubscription(sub); err != nil {
+			writeResult(rw, "?", nil, err)
+			return
+		}
+
+		if err := ds.subscriptions.Put(sub); err != nil {
+			writeResult(rw, "?", nil, err)
+			return
+		}
+		writeResult(rw, "subscription", sub, nil)
+
+	case http.MethodDelete:
+		deleted, err := ds.subscriptions.Delete(name)
+		if err != nil {
+			writeResult(rw, "?", nil, err)
+			return
+		}
+		writeResult(rw, "deleted", deleted, nil)
+
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReconcile backs POST /resources/reconcile. The caller submits the full desired state of
+// subscriptions; the response reports the create/update/delete plan that was applied, per object,
+// so a controller loop can requeue on any Failed entries.
+func (ds *EPICSDatasource) handleReconcile(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var desiredDoc struct {
+		Subscriptions []Subscription `json:"subscriptions"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&desiredDoc); err != nil {
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	for _, s := range desiredDoc.Subscriptions {
+		if err := validateSubscription(s); err != nil {
+			writeResult(rw, "?", nil, err)
+			return
+		}
+	}
+
+	results := ds.reconcileSubscriptions(desiredDoc.Subscriptions)
+	writeResult(rw, "results", results, nil)
+}
+
+// PVMetadata is the subset of a PV's archiver type info relevant to a panel auto-populating units
+// and axis bounds: engineering units, display precision, high/low operating range, and whether
+// the PV is actively being archived.
+type PVMetadata struct {
+	Name        string  `json:"pvName"`
+	EGU         string  `json:"EGU"`
+	Precision   int     `json:"precision,string"`
+	HOPR        float64 `json:"HOPR,string"`
+	LOPR        float64 `json:"LOPR,string"`
+	IsArchiving bool    `json:"isArchiving,string"`
+}
+
+// GetPVMetadata fetches a single PV's archiver type info for the /resources/pvs/:name/metadata
+// companion route.
+func (ds *EPICSDatasource) GetPVMetadata(ctx context.Context, pluginCtx backend.PluginContext, server string, manageport string, pv string) (*PVMetadata, error) {
+	params := url.Values{}
+	params.Add("pv", pv)
+
+	metaurl := fmt.Sprintf("http://%s:%s/mgmt/bpl/getPVTypeInfo?%s", server, manageport, params.Encode())
+
+	httpreq, err := http.NewRequestWithContext(ctx, http.MethodGet, metaurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := ds.getHTTPClient(ctx, pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(httpreq)
+	if cerr := classifyArchiverError(res, err); cerr != nil {
+		if res != nil {
+			res.Body.Close()
+		}
+		return nil, cerr
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta PVMetadata
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("unexpected response shape from getPVTypeInfo: %w", err)
+	}
+
+	return &meta, nil
+}
+
+// pvSearchCacheTTL is intentionally much shorter than the channel list cache's: this is guarding
+// against a single editor's keystroke storm, not amortizing load across many dashboard loads.
+const pvSearchCacheTTL = 5 * time.Second
+
+type pvSearchCacheEntry struct {
+	etag      string
+	matches   []string
+	fetchedAt time.Time
+}
+
+func pvSearchCacheKey(datasourceUID, query string) string {
+	return datasourceUID + "|" + query
+}
+
+// expandGlobQuery lets the typeahead box behave like a substring search when the user hasn't
+// typed any glob characters themselves, while still passing an explicit "k1:dcs:*:az" pattern
+// straight through to the archiver untouched.
+func expandGlobQuery(query string) string {
+	if query == "" {
+		return "*"
+	}
+	if strings.ContainsAny(query, "*?") {
+		return query
+	}
+	return "*" + query + "*"
+}
+
+// handleResourcePVsSearch backs GET /resources/pvs, driving a query-editor typeahead. Results are
+// cached for a few seconds per (datasourceUID, query) and served with an ETag so a burst of
+// keystrokes against the same prefix doesn't hammer the archiver.
+func (ds *EPICSDatasource) handleResourcePVsSearch(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := req.Context()
+	pluginCtx := httpadapter.PluginConfigFromContext(ctx)
+	config, err := LoadSettings(pluginCtx)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "settings load error")
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	params := req.URL.Query()
+	query := params.Get("query")
+
+	limit := searchDefaultLimit
+	if raw := params.Get("limit"); raw != "" {
+		if parsed, perr := strconv.Atoi(raw); perr == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+
+	key := pvSearchCacheKey(pluginCtx.DataSourceInstanceSettings.UID, query)
+
+	ds.pvSearchCacheMu.Lock()
+	entry, ok := ds.pvSearchCacheEntries[key]
+	ds.pvSearchCacheMu.Unlock()
+
+	if !ok || time.Since(entry.fetchedAt) >= pvSearchCacheTTL {
+		matches, err := ds.SearchPVs(ctx, pluginCtx, config.Server, config.ManagePort, expandGlobQuery(query), limit)
+		if err != nil {
+			log.DefaultLogger.Error(fl() + "pv search error: " + err.Error())
+			writeResult(rw, "?", nil, err)
+			return
+		}
+
+		entry = &pvSearchCacheEntry{
+			etag:      fmt.Sprintf(`"%x"`, sha1.Sum([]byte(strings.Join(matches, ",")))),
+			matches:   matches,
+			fetchedAt: time.Now(),
+		}
+
+		ds.pvSearchCacheMu.Lock()
+		ds.pvSearchCacheEntries[key] = entry
+		ds.pvSearchCacheMu.Unlock()
+	}
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" && inm == entry.etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.Header().Set("ETag", entry.etag)
+	writeResult(rw, "pvs", entry.matches, nil)
+}
+
+// handleResourcePVMetadata backs GET /resources/pvs/:name/metadata, returning EGU, precision,
+// HOPR/LOPR, and archival state for a single PV.
+func (ds *EPICSDatasource) handleResourcePVMetadata(rw http.ResponseWriter, req *http.Request) {
+	log.DefaultLogger.Debug(fl() + "resource call url=" + req.URL.String() + "  method=" + req.Method)
+
+	if req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	rest := strings.TrimPrefix(req.URL.Path, "/resources/pvs/")
+	name := strings.TrimSuffix(rest, "/metadata")
+	if name == "" || name == rest {
+		writeResult(rw, "?", nil, fmt.Errorf("expected path /resources/pvs/:name/metadata"))
+		return
+	}
+
+	ctx := req.Context()
+	pluginCtx := httpadapter.PluginConfigFromContext(ctx)
+	config, err := LoadSettings(pluginCtx)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "settings load error")
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	meta, err := ds.GetPVMetadata(ctx, pluginCtx, config.Server, config.ManagePort, name)
+	if err != nil {
+		log.DefaultLogger.Error(fl() + "pv metadata retrieve error: " + err.Error())
+		writeResult(rw, "?", nil, err)
+		return
+	}
+
+	writeResult(rw, "metadata", meta, nil)
+}
+
 type instanceSettings struct {
 	httpClient *http.Client
 }
 
-func newDataSourceInstance(setting backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
-	return &instanceSettings{
-		httpClient: &http.Client{},
-	}, nil
+// getHTTPClient retrieves (creating it if necessary) the per-instance HTTP client built by
+// newDataSourceInstance from pluginCtx.DataSourceInstanceSettings, so archiver requests honor
+// that instance's TLS/basic auth/custom headers/OAuth passthrough/timeout configuration instead
+// of a bare, unconfigured client.
+func (ds *EPICSDatasource) getHTTPClient(ctx context.Context, pluginCtx backend.PluginContext) (*http.Client, error) {
+	inst, err := ds.im.Get(ctx, pluginCtx)
+	if err != nil {
+		return nil, fmt.Errorf("datasource instance: %w", err)
+	}
+	return inst.(*instanceSettings).httpClient, nil
+}
+
+// instanceTimeout reads the optional "timeoutSeconds" key this plugin adds to JSONData (on top
+// of whatever setting.HTTPClientOptions already derived) and returns it as a Duration, or
+// zero if it's unset so the SDK's own default timeout is left alone.
+func instanceTimeout(jsonData []byte) (time.Duration, error) {
+	if len(jsonData) == 0 {
+		return 0, nil
+	}
+
+	var parsed struct {
+		TimeoutSeconds int `json:"timeoutSeconds"`
+	}
+	if err := json.Unmarshal(jsonData, &parsed); err != nil {
+		return 0, fmt.Errorf("error reading settings: %w", err)
+	}
+
+	if parsed.TimeoutSeconds <= 0 {
+		return 0, nil
+	}
+
+	return time.Duration(parsed.TimeoutSeconds) * time.Second, nil
+}
+
+// newDataSourceInstance builds the per-datasource HTTP client from the standard Grafana
+// datasource config UI fields (URL, TLS client auth/CA/skip-verify/server name, basic auth,
+// custom headers, forwarded OAuth identity, proxy) via httpclient.Options/httpclient.New, so this
+// plugin's requests go through the same SDK middleware chain (tracing, OAuth passthrough, custom
+// headers) as any other Grafana datasource, instead of a bare, unconfigured *http.Client.
+func newDataSourceInstance(ctx context.Context, setting backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	opts, err := setting.HTTPClientOptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("http client options: %w", err)
+	}
+
+	if timeout, err := instanceTimeout(setting.JSONData); err != nil {
+		return nil, err
+	} else if timeout > 0 {
+		opts.Timeouts.Timeout = timeout
+	}
+
+	httpClient, err := httpclient.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("building http client: %w", err)
+	}
+
+	return &instanceSettings{httpClient: httpClient}, nil
 }
 
 func (s *instanceSettings) Dispose() {
-	// Called before creatinga a new instance to allow plugin authors
-	// to cleanup.
+	// Called before creating a new instance to allow plugin authors to clean up.
+	s.httpClient.CloseIdleConnections()
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstanceTimeout(t *testing.T) {
+	cases := []struct {
+		name    string
+		json    string
+		want    int // seconds
+		wantErr bool
+	}{
+		{name: "empty settings", json: "", want: 0},
+		{name: "no timeout key", json: `{"server":"archiver"}`, want: 0},
+		{name: "zero timeout", json: `{"timeoutSeconds":0}`, want: 0},
+		{name: "negative timeout", json: `{"timeoutSeconds":-5}`, want: 0},
+		{name: "positive timeout", json: `{"timeoutSeconds":30}`, want: 30},
+		{name: "invalid json", json: `{`, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := instanceTimeout([]byte(tc.json))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Seconds() != float64(tc.want) {
+				t.Fatalf("instanceTimeout(%q) = %v, want %ds", tc.json, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildAggregateFrameBoundarySample(t *testing.T) {
+	from := time.Unix(1000, 0)
+	qm := queryModel{RefId: "A", Aggregation: AGGREGATION_MEAN}
+
+	// The archiver commonly returns one sample timestamped slightly before "from" so the chart
+	// has a value to start from - that sample must land in the first bucket, not get dropped for
+	// having a negative index.
+	times := []time.Time{
+		from.Add(-500 * time.Millisecond),
+		from.Add(200 * time.Millisecond),
+		from.Add(1200 * time.Millisecond),
+	}
+	values := []float64{1, 3, 5}
+	severities := []int64{0, 0, 0}
+
+	frame, err := buildAggregateFrame(qm, from, 1000, times, values, severities)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	valid := frame.Fields[9]
+	if valid.Len() != 2 {
+		t.Fatalf("got %d buckets, want 2 (boundary sample should fold into bucket 0, not grow the range)", valid.Len())
+	}
+
+	mean, _ := frame.Fields[1].At(0).(float64)
+	if mean != 2 {
+		t.Fatalf("bucket 0 mean = %v, want 2 (mean of boundary sample 1 and in-range sample 3)", mean)
+	}
+}
+
+func TestBuildAggregateFrameAllInvalid(t *testing.T) {
+	from := time.Unix(1000, 0)
+	qm := queryModel{RefId: "A", Aggregation: AGGREGATION_MEAN}
+
+	times := []time.Time{from.Add(100 * time.Millisecond)}
+	values := []float64{42}
+	severities := []int64{EPICS_SEVERITY_INVALID}
+
+	frame, err := buildAggregateFrame(qm, from, 1000, times, values, severities)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	valid := frame.Fields[9]
+	if valid.Len() != 1 {
+		t.Fatalf("got %d buckets, want 1 (bucket should still be seen, just unmarked valid)", valid.Len())
+	}
+	if isValid, _ := valid.At(0).(bool); isValid {
+		t.Fatalf("bucket with only an invalid-severity sample should not be marked valid")
+	}
+}
+
+func TestReconcileSubscriptionsIdempotent(t *testing.T) {
+	ds := &EPICSDatasource{subscriptions: newSubscriptionRegistry(t.TempDir() + "/subscriptions.json")}
+
+	desired := []Subscription{
+		{Name: "a", ApplianceURL: "http://archiver", PVGlob: "k1:dcs:*"},
+		{Name: "b", ApplianceURL: "http://archiver", PVGlob: "k0:met:*"},
+	}
+
+	first := ds.reconcileSubscriptions(desired)
+	for _, r := range first {
+		if r.Action != "create" || r.Status != ReconcileApplied {
+			t.Fatalf("first reconcile: %s got action=%s status=%s, want create/Applied", r.Name, r.Action, r.Status)
+		}
+	}
+
+	second := ds.reconcileSubscriptions(desired)
+	for _, r := range second {
+		if r.Action != "none" || r.Status != ReconcileUnchanged {
+			t.Fatalf("second reconcile with identical desired state: %s got action=%s status=%s, want none/Unchanged", r.Name, r.Action, r.Status)
+		}
+	}
+
+	// Dropping "b" from desired should delete it, and reconciling that same narrowed state again
+	// should be a no-op.
+	narrowed := desired[:1]
+	third := ds.reconcileSubscriptions(narrowed)
+	foundDelete := false
+	for _, r := range third {
+		if r.Name == "b" {
+			foundDelete = true
+			if r.Action != "delete" || r.Status != ReconcileApplied {
+				t.Fatalf("got action=%s status=%s for removed subscription, want delete/Applied", r.Action, r.Status)
+			}
+		}
+	}
+	if !foundDelete {
+		t.Fatalf("expected a delete result for subscription %q", "b")
+	}
+
+	fourth := ds.reconcileSubscriptions(narrowed)
+	if len(fourth) != 1 || fourth[0].Action != "none" || fourth[0].Status != ReconcileUnchanged {
+		t.Fatalf("reconciling the narrowed state twice should be a no-op, got %+v", fourth)
+	}
+}